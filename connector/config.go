@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"io/ioutil"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the on-disk representation of a single configured connector.
+// Settings holds provider-specific fields (bind DN, client_id, issuer URL,
+// group-attribute mapping, ...); each Build* function decodes the subset it
+// cares about.
+type Config struct {
+	Name        string                 `yaml:"name"`
+	Type        string                 `yaml:"type"`
+	DisplayName string                 `yaml:"display_name"`
+	Settings    map[string]interface{} `yaml:"settings"`
+}
+
+// file is the top-level shape of a connectors.yaml.
+type file struct {
+	Connectors []Config `yaml:"connectors"`
+}
+
+// LoadConfig reads and parses a connector config file.
+func LoadConfig(path string) ([]Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read connector config")
+	}
+
+	var f file
+	if err := yaml.Unmarshal(raw, &f); err != nil {
+		return nil, errors.Wrap(err, "failed to parse connector config")
+	}
+
+	return f.Connectors, nil
+}
+
+// Build constructs the Connector described by cfg.
+func Build(cfg Config) (Connector, error) {
+	switch cfg.Type {
+	case "ldap":
+		return NewLDAP(cfg)
+	case "saml":
+		return NewSAML(cfg)
+	case "github":
+		return NewGitHub(cfg)
+	case "oidc":
+		return NewOIDC(cfg)
+	case "keystone":
+		return NewKeystone(cfg)
+	default:
+		return nil, errors.Errorf("unknown connector type: %s", cfg.Type)
+	}
+}
+
+func setting(cfg Config, key string) string {
+	v, _ := cfg.Settings[key].(string)
+	return v
+}
+
+// mustParseURL parses raw as a URL, returning nil if it's empty or
+// malformed. Connector constructors validate the fields they actually need,
+// so a bad value surfaces there rather than as a confusing nil pointer.
+func mustParseURL(raw string) *url.URL {
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+
+	return u
+}
@@ -0,0 +1,103 @@
+// Package connector provides a pluggable framework for external
+// authenticators. A Connector owns the details of a single identity
+// provider (LDAP, SAML, GitHub, generic OIDC, Keystone, ...); skribe's http
+// package only ever talks to the Connector interface.
+package connector
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned by a Connector method that the underlying
+// provider doesn't implement, e.g. HandleCallback on a direct-bind
+// connector like LDAP, or Refresh on a provider with no refresh tokens.
+var ErrNotSupported = errors.New("not supported by this connector")
+
+// Result is what a Connector resolves a successful authentication to. The
+// http layer reconciles this against docshelf.UserStore to produce a
+// docshelf.User.
+type Result struct {
+	Email         string
+	Groups        []string
+	IdentityToken string // upstream access/ID token, kept so connectors can reuse the session without a password
+	RefreshToken  string
+}
+
+// Metadata describes a connector for the /auth/connectors discovery
+// endpoint, so the UI can render an appropriate login option.
+type Metadata struct {
+	Name        string `yaml:"name" json:"name"`
+	DisplayName string `yaml:"display_name" json:"display_name"`
+	Type        string `yaml:"type" json:"type"`
+}
+
+// Connector is a single external authenticator. Not every method applies to
+// every provider: direct-bind connectors (LDAP, Keystone) do their work in
+// Login and return ErrNotSupported from HandleCallback; redirect-based
+// connectors (OIDC, SAML, GitHub) do the opposite.
+type Connector interface {
+	// Login starts the connector's authentication flow. Redirect-based
+	// connectors return a URL the browser should be sent to and a nil
+	// Result; codeChallenge (S256 over a server-held verifier) is appended
+	// to that URL as the PKCE code_challenge so the authorization code
+	// minted by the provider can't be redeemed by anyone who doesn't also
+	// hold the verifier. Direct-bind connectors read credentials off r,
+	// authenticate immediately, ignore codeChallenge entirely, and return a
+	// Result with an empty redirect URL.
+	Login(ctx context.Context, r *http.Request, state, codeChallenge string) (redirectURL string, result *Result, err error)
+
+	// HandleCallback completes a redirect-based flow from the provider's
+	// callback request. codeVerifier is the PKCE verifier stashed for the
+	// state this callback redeemed, and must be presented back to the
+	// provider's token endpoint alongside the authorization code.
+	HandleCallback(ctx context.Context, r *http.Request, codeVerifier string) (Result, error)
+
+	// Refresh exchanges a previously-issued upstream refresh token for a
+	// fresh Result.
+	Refresh(ctx context.Context, refreshToken string) (Result, error)
+
+	// Identity describes the connector for discovery.
+	Identity() Metadata
+}
+
+// Registry holds the connectors a Server has been configured with, keyed by
+// the name they were registered under (the {connector} path segment).
+type Registry struct {
+	connectors map[string]Connector
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{connectors: make(map[string]Connector)}
+}
+
+// Register adds a connector under name, overwriting any existing connector
+// with the same name.
+func (r *Registry) Register(name string, c Connector) {
+	r.connectors[name] = c
+}
+
+// Get returns the connector registered under name.
+func (r *Registry) Get(name string) (Connector, bool) {
+	c, ok := r.connectors[name]
+	return c, ok
+}
+
+// Len returns the number of registered connectors.
+func (r *Registry) Len() int {
+	return len(r.connectors)
+}
+
+// List returns discovery metadata for every registered connector, suitable
+// for the /auth/connectors endpoint.
+func (r *Registry) List() []Metadata {
+	meta := make([]Metadata, 0, len(r.connectors))
+	for _, c := range r.connectors {
+		meta = append(meta, c.Identity())
+	}
+
+	return meta
+}
@@ -0,0 +1,123 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	oauth2github "golang.org/x/oauth2/github"
+)
+
+// githubAPI is the base URL for the GitHub REST API, broken out so tests
+// can point it at a fake server.
+var githubAPI = "https://api.github.com"
+
+// GitHub authenticates via GitHub's standard OAuth2 app flow.
+type GitHub struct {
+	name        string
+	displayName string
+	oauth2Cfg   oauth2.Config
+	orgFilter   string // if set, only members of this org may log in
+}
+
+// NewGitHub builds a GitHub connector from cfg.
+func NewGitHub(cfg Config) (*GitHub, error) {
+	clientID := setting(cfg, "client_id")
+	clientSecret := setting(cfg, "client_secret")
+	if clientID == "" || clientSecret == "" {
+		return nil, errors.New("github connector requires client_id and client_secret")
+	}
+
+	return &GitHub{
+		name:        cfg.Name,
+		displayName: cfg.DisplayName,
+		orgFilter:   setting(cfg, "org"),
+		oauth2Cfg: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  setting(cfg, "redirect_uri"),
+			Endpoint:     oauth2github.Endpoint,
+			Scopes:       []string{"read:user", "read:org", "user:email"},
+		},
+	}, nil
+}
+
+// Login implements Connector.
+func (c *GitHub) Login(ctx context.Context, r *http.Request, state, codeChallenge string) (string, *Result, error) {
+	return c.oauth2Cfg.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil, nil
+}
+
+// HandleCallback implements Connector.
+func (c *GitHub) HandleCallback(ctx context.Context, r *http.Request, codeVerifier string) (Result, error) {
+	token, err := c.oauth2Cfg.Exchange(ctx, r.URL.Query().Get("code"),
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier),
+	)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to exchange code")
+	}
+
+	client := c.oauth2Cfg.Client(ctx, token)
+
+	var user struct {
+		Email string `json:"email"`
+	}
+	if err := getJSON(client, githubAPI+"/user", &user); err != nil {
+		return Result{}, errors.Wrap(err, "failed to fetch github user")
+	}
+
+	var orgs []struct {
+		Login string `json:"login"`
+	}
+	if err := getJSON(client, githubAPI+"/user/orgs", &orgs); err != nil {
+		return Result{}, errors.Wrap(err, "failed to fetch github orgs")
+	}
+
+	groups := make([]string, 0, len(orgs))
+	member := c.orgFilter == ""
+	for _, org := range orgs {
+		groups = append(groups, org.Login)
+		if org.Login == c.orgFilter {
+			member = true
+		}
+	}
+
+	if !member {
+		return Result{}, errors.Errorf("user is not a member of required org %q", c.orgFilter)
+	}
+
+	return Result{
+		Email:         user.Email,
+		Groups:        groups,
+		IdentityToken: token.AccessToken,
+	}, nil
+}
+
+// Refresh implements Connector. Classic GitHub OAuth apps issue
+// non-expiring tokens, so there's nothing to refresh.
+func (c *GitHub) Refresh(ctx context.Context, refreshToken string) (Result, error) {
+	return Result{}, ErrNotSupported
+}
+
+// Identity implements Connector.
+func (c *GitHub) Identity() Metadata {
+	return Metadata{Name: c.name, DisplayName: c.displayName, Type: "github"}
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status from %s: %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
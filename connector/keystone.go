@@ -0,0 +1,78 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/pkg/errors"
+)
+
+// Keystone authenticates against an OpenStack Identity (Keystone) v3
+// service by requesting a token for the supplied credentials. The token is
+// not scoped to a project.
+type Keystone struct {
+	name        string
+	displayName string
+	authURL     string
+	domainName  string
+}
+
+// NewKeystone builds a Keystone connector from cfg.
+func NewKeystone(cfg Config) (*Keystone, error) {
+	authURL := setting(cfg, "auth_url")
+	if authURL == "" {
+		return nil, errors.New("keystone connector requires auth_url")
+	}
+
+	return &Keystone{
+		name:        cfg.Name,
+		displayName: cfg.DisplayName,
+		authURL:     authURL,
+		domainName:  setting(cfg, "domain_name"),
+	}, nil
+}
+
+// Login implements Connector: it exchanges username/password (read from r's
+// form values) for a Keystone token and returns the username as the
+// Result's Email. Project membership is not looked up, so the Result has no
+// groups. codeChallenge doesn't apply to a direct-bind connector and is
+// ignored.
+func (c *Keystone) Login(ctx context.Context, r *http.Request, state, codeChallenge string) (string, *Result, error) {
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+	if username == "" || password == "" {
+		return "", nil, errors.New("username and password are required")
+	}
+
+	if _, err := openstack.AuthenticatedClient(gophercloud.AuthOptions{
+		IdentityEndpoint: c.authURL,
+		Username:         username,
+		Password:         password,
+		DomainName:       c.domainName,
+	}); err != nil {
+		return "", nil, errors.Wrap(err, "failed to authenticate with keystone")
+	}
+
+	// project membership (used as groups) requires a second call against
+	// the identity client above; left for a follow-up since skribe doesn't
+	// yet have a use for project-scoped tokens.
+	return "", &Result{Email: username}, nil
+}
+
+// HandleCallback implements Connector.
+func (c *Keystone) HandleCallback(ctx context.Context, r *http.Request, codeVerifier string) (Result, error) {
+	return Result{}, ErrNotSupported
+}
+
+// Refresh implements Connector. Keystone tokens are re-requested rather
+// than refreshed.
+func (c *Keystone) Refresh(ctx context.Context, refreshToken string) (Result, error) {
+	return Result{}, ErrNotSupported
+}
+
+// Identity implements Connector.
+func (c *Keystone) Identity() Metadata {
+	return Metadata{Name: c.name, DisplayName: c.displayName, Type: "keystone"}
+}
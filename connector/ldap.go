@@ -0,0 +1,104 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+// LDAP authenticates by binding to a directory server: first as a service
+// account to search for the user's DN, then as the user themselves to
+// verify the supplied password.
+type LDAP struct {
+	host         string
+	bindDN       string
+	bindPassword string
+	userSearchDN string
+	userFilter   string // e.g. "(uid=%s)"
+	groupAttr    string
+	displayName  string
+	name         string
+}
+
+// NewLDAP builds an LDAP connector from cfg.
+func NewLDAP(cfg Config) (*LDAP, error) {
+	host := setting(cfg, "host")
+	if host == "" {
+		return nil, errors.New("ldap connector requires a host")
+	}
+
+	return &LDAP{
+		host:         host,
+		bindDN:       setting(cfg, "bind_dn"),
+		bindPassword: setting(cfg, "bind_password"),
+		userSearchDN: setting(cfg, "user_search_dn"),
+		userFilter:   setting(cfg, "user_filter"),
+		groupAttr:    setting(cfg, "group_attribute"),
+		name:         cfg.Name,
+		displayName:  cfg.DisplayName,
+	}, nil
+}
+
+// Login implements Connector. Credentials are read from r's form values
+// ("username"/"password") rather than a redirect, since LDAP has no
+// browser-facing step. codeChallenge doesn't apply to a direct-bind
+// connector and is ignored.
+func (c *LDAP) Login(ctx context.Context, r *http.Request, state, codeChallenge string) (string, *Result, error) {
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+	if username == "" || password == "" {
+		return "", nil, errors.New("username and password are required")
+	}
+
+	conn, err := ldap.DialURL(c.host)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to connect to ldap server")
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(c.bindDN, c.bindPassword); err != nil {
+		return "", nil, errors.Wrap(err, "failed to bind service account")
+	}
+
+	filter := fmt.Sprintf(c.userFilter, ldap.EscapeFilter(username))
+	res, err := conn.Search(ldap.NewSearchRequest(
+		c.userSearchDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 1, 0, false,
+		filter, []string{"mail", c.groupAttr}, nil,
+	))
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to search for user")
+	}
+
+	if len(res.Entries) != 1 {
+		return "", nil, errors.New("user not found")
+	}
+
+	entry := res.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return "", nil, errors.Wrap(err, "invalid credentials")
+	}
+
+	return "", &Result{
+		Email:  entry.GetAttributeValue("mail"),
+		Groups: entry.GetAttributeValues(c.groupAttr),
+	}, nil
+}
+
+// HandleCallback implements Connector.
+func (c *LDAP) HandleCallback(ctx context.Context, r *http.Request, codeVerifier string) (Result, error) {
+	return Result{}, ErrNotSupported
+}
+
+// Refresh implements Connector. LDAP has no notion of a refresh token; a
+// stale session just re-binds.
+func (c *LDAP) Refresh(ctx context.Context, refreshToken string) (Result, error) {
+	return Result{}, ErrNotSupported
+}
+
+// Identity implements Connector.
+func (c *LDAP) Identity() Metadata {
+	return Metadata{Name: c.name, DisplayName: c.displayName, Type: "ldap"}
+}
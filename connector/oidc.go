@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"context"
+	"net/http"
+
+	gooidc "github.com/coreos/go-oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// OIDC is a generic OpenID Connect connector: standard authorization code
+// flow, ID token validation, and refresh_token support. GitHub and Keystone
+// get their own connectors because neither speaks plain OIDC.
+type OIDC struct {
+	name        string
+	displayName string
+
+	clientID    string
+	oauth2Cfg   oauth2.Config
+	provider    *gooidc.Provider
+	verifier    *gooidc.IDTokenVerifier
+	groupsClaim string
+}
+
+// NewOIDC builds a generic OIDC connector from cfg. It contacts the
+// issuer's discovery document, so construction can fail at startup if the
+// issuer is unreachable or misconfigured.
+func NewOIDC(cfg Config) (*OIDC, error) {
+	issuer := setting(cfg, "issuer_url")
+	clientID := setting(cfg, "client_id")
+	if issuer == "" || clientID == "" {
+		return nil, errors.New("oidc connector requires issuer_url and client_id")
+	}
+
+	provider, err := gooidc.NewProvider(context.Background(), issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch oidc discovery document")
+	}
+
+	groupsClaim := setting(cfg, "groups_claim")
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	return &OIDC{
+		name:        cfg.Name,
+		displayName: cfg.DisplayName,
+		clientID:    clientID,
+		groupsClaim: groupsClaim,
+		provider:    provider,
+		verifier:    provider.Verifier(&gooidc.Config{ClientID: clientID}),
+		oauth2Cfg: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: setting(cfg, "client_secret"),
+			RedirectURL:  setting(cfg, "redirect_uri"),
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{gooidc.ScopeOpenID, "profile", "email", gooidc.ScopeOfflineAccess},
+		},
+	}, nil
+}
+
+// Login implements Connector.
+func (c *OIDC) Login(ctx context.Context, r *http.Request, state, codeChallenge string) (string, *Result, error) {
+	return c.oauth2Cfg.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil, nil
+}
+
+// HandleCallback implements Connector.
+func (c *OIDC) HandleCallback(ctx context.Context, r *http.Request, codeVerifier string) (Result, error) {
+	token, err := c.oauth2Cfg.Exchange(ctx, r.URL.Query().Get("code"),
+		oauth2.SetAuthURLParam("code_verifier", codeVerifier),
+	)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to exchange code")
+	}
+
+	return c.resultFromToken(ctx, token)
+}
+
+// Refresh implements Connector.
+func (c *OIDC) Refresh(ctx context.Context, refreshToken string) (Result, error) {
+	token, err := c.oauth2Cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to refresh token")
+	}
+
+	return c.resultFromToken(ctx, token)
+}
+
+func (c *OIDC) resultFromToken(ctx context.Context, token *oauth2.Token) (Result, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Result{}, errors.New("token response did not contain an id_token")
+	}
+
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "failed to verify id_token")
+	}
+
+	var claims struct {
+		Email  string   `json:"email"`
+		Groups []string `json:"-"`
+	}
+
+	if err := idToken.Claims(&claims); err != nil {
+		return Result{}, errors.Wrap(err, "failed to decode id_token claims")
+	}
+
+	var groupClaims map[string]interface{}
+	if err := idToken.Claims(&groupClaims); err == nil {
+		if raw, ok := groupClaims[c.groupsClaim].([]interface{}); ok {
+			for _, g := range raw {
+				if s, ok := g.(string); ok {
+					claims.Groups = append(claims.Groups, s)
+				}
+			}
+		}
+	}
+
+	return Result{
+		Email:         claims.Email,
+		Groups:        claims.Groups,
+		IdentityToken: rawIDToken,
+		RefreshToken:  token.RefreshToken,
+	}, nil
+}
+
+// Identity implements Connector.
+func (c *OIDC) Identity() Metadata {
+	return Metadata{Name: c.name, DisplayName: c.displayName, Type: "oidc"}
+}
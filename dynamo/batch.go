@@ -0,0 +1,236 @@
+package dynamo
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dyna "github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
+	"github.com/docshelf/docshelf"
+	"github.com/pkg/errors"
+)
+
+// isConditionalCheckFailure reports whether err is the error DynamoDB
+// returns when a PutItem/TransactWriteItems ConditionExpression doesn't
+// hold, i.e. someone else won the race.
+func isConditionalCheckFailure(err error) bool {
+	apiErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	return apiErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// batchGetLimit is the maximum number of keys DynamoDB accepts in a single
+// BatchGetItem call.
+const batchGetLimit = 100
+
+// batchWriteLimit is the maximum number of items DynamoDB accepts in a
+// single BatchWriteItem or TransactWriteItems call.
+const batchWriteLimit = 25
+
+// maxBatchRetries bounds how many times a batch call retries DynamoDB's
+// UnprocessedKeys/UnprocessedItems before giving up. DynamoDB is allowed to
+// return fewer items/writes than requested under throttling and expects
+// callers to retry the leftovers; without a retry loop those leftovers are
+// silently dropped from a listing, or silently never written at all.
+const maxBatchRetries = 5
+
+// batchRetryBackoff returns how long to wait before retrying a batch
+// request that came back with unprocessed keys/items, backing off linearly
+// so a throttled table gets a chance to recover.
+func batchRetryBackoff(attempt int) time.Duration {
+	return time.Duration(attempt) * 50 * time.Millisecond
+}
+
+// chunkStrings splits items into slices of at most size, preserving order.
+func chunkStrings(items []string, size int) [][]string {
+	if len(items) == 0 {
+		return nil
+	}
+
+	chunks := make([][]string, 0, (len(items)+size-1)/size)
+	for size < len(items) {
+		chunks = append(chunks, items[:size])
+		items = items[size:]
+	}
+
+	return append(chunks, items)
+}
+
+// batchGetDocs fetches the docshelf Doc metadata (no Content) for paths
+// using BatchGetItem, chunked to stay under DynamoDB's per-request limit
+// and retried up to maxBatchRetries if DynamoDB returns UnprocessedKeys.
+// Order is not guaranteed to match paths.
+func (s Store) batchGetDocs(ctx context.Context, paths []string) ([]docshelf.Doc, error) {
+	var docs []docshelf.Doc
+
+	for _, chunk := range chunkStrings(paths, batchGetLimit) {
+		keys := make([]map[string]dynamodb.AttributeValue, 0, len(chunk))
+		for _, path := range chunk {
+			key, err := makeKey("path", path)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make key")
+			}
+
+			keys = append(keys, key)
+		}
+
+		requestItems := map[string]dynamodb.KeysAndAttributes{s.docTable: {Keys: keys}}
+
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return nil, errors.Errorf("batch get docs still had unprocessed keys after %d retries", maxBatchRetries)
+			}
+
+			if attempt > 0 {
+				time.Sleep(batchRetryBackoff(attempt))
+			}
+
+			input := dynamodb.BatchGetItemInput{RequestItems: requestItems}
+			resp, err := s.client.BatchGetItemRequest(&input).Send()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to batch get docs")
+			}
+
+			for _, item := range resp.Responses[s.docTable] {
+				var doc docshelf.Doc
+				if err := dyna.UnmarshalMap(item, &doc); err != nil {
+					return nil, errors.Wrap(err, "failed to unmarshal doc")
+				}
+
+				if doc.Deleted {
+					continue
+				}
+
+				docs = append(docs, doc)
+			}
+
+			requestItems = resp.UnprocessedKeys
+		}
+	}
+
+	return docs, nil
+}
+
+// batchGetTags fetches Tag rows for tags using BatchGetItem, chunked to
+// stay under DynamoDB's per-request limit and retried up to
+// maxBatchRetries if DynamoDB returns UnprocessedKeys. Missing tags are
+// simply absent from the result map rather than an error, mirroring the
+// single-tag getItem behavior callers already rely on.
+func (s Store) batchGetTags(ctx context.Context, tags []string) (map[string]Tag, error) {
+	found := make(map[string]Tag, len(tags))
+
+	for _, chunk := range chunkStrings(tags, batchGetLimit) {
+		keys := make([]map[string]dynamodb.AttributeValue, 0, len(chunk))
+		for _, t := range chunk {
+			key, err := makeKey("tag", t)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to make key")
+			}
+
+			keys = append(keys, key)
+		}
+
+		requestItems := map[string]dynamodb.KeysAndAttributes{s.tagTable: {Keys: keys}}
+
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return nil, errors.Errorf("batch get tags still had unprocessed keys after %d retries", maxBatchRetries)
+			}
+
+			if attempt > 0 {
+				time.Sleep(batchRetryBackoff(attempt))
+			}
+
+			input := dynamodb.BatchGetItemInput{RequestItems: requestItems}
+			resp, err := s.client.BatchGetItemRequest(&input).Send()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to batch get tags")
+			}
+
+			for _, item := range resp.Responses[s.tagTable] {
+				var tag Tag
+				if err := dyna.UnmarshalMap(item, &tag); err != nil {
+					return nil, errors.Wrap(err, "failed to unmarshal tag")
+				}
+
+				found[tag.Tag] = tag
+			}
+
+			requestItems = resp.UnprocessedKeys
+		}
+	}
+
+	return found, nil
+}
+
+// PutDocsBulk writes multiple docs in batches of up to 25, the DynamoDB
+// BatchWriteItem limit, retried up to maxBatchRetries if DynamoDB returns
+// UnprocessedItems. Unlike PutDoc, bulk writes skip the optimistic
+// concurrency check and record no revision history; each doc's content is
+// still written to the file store before its batch is sent. It's meant for
+// bulk imports where the caller owns conflict resolution.
+func (s Store) PutDocsBulk(ctx context.Context, docs []docshelf.Doc) error {
+	now := time.Now()
+
+	for start := 0; start < len(docs); start += batchWriteLimit {
+		end := start + batchWriteLimit
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		batch := docs[start:end]
+		requests := make([]dynamodb.WriteRequest, 0, len(batch))
+		for i := range batch {
+			doc := batch[i]
+			if doc.Path == "" {
+				return errors.New("can not create a new doc without a path")
+			}
+
+			if doc.CreatedAt.IsZero() {
+				doc.CreatedAt = now
+			}
+			doc.UpdatedAt = now
+
+			if err := s.fs.WriteFile(doc.Path, []byte(doc.Content)); err != nil {
+				return errors.Wrapf(err, "failed to write doc to file store: %s", doc.Path)
+			}
+			doc.Content = ""
+
+			marshaled, err := dyna.MarshalMap(&doc)
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal doc for dynamo")
+			}
+
+			requests = append(requests, dynamodb.WriteRequest{
+				PutRequest: &dynamodb.PutRequest{Item: marshaled},
+			})
+		}
+
+		requestItems := map[string][]dynamodb.WriteRequest{s.docTable: requests}
+
+		for attempt := 0; len(requestItems) > 0; attempt++ {
+			if attempt > maxBatchRetries {
+				return errors.Errorf("batch write docs still had unprocessed items after %d retries", maxBatchRetries)
+			}
+
+			if attempt > 0 {
+				time.Sleep(batchRetryBackoff(attempt))
+			}
+
+			input := dynamodb.BatchWriteItemInput{RequestItems: requestItems}
+			resp, err := s.client.BatchWriteItemRequest(&input).Send()
+			if err != nil {
+				return errors.Wrap(err, "failed to batch write docs")
+			}
+
+			requestItems = resp.UnprocessedItems
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package dynamo
+
+import "testing"
+
+func TestChunkStrings(t *testing.T) {
+	cases := []struct {
+		name  string
+		items []string
+		size  int
+		want  [][]string
+	}{
+		{"empty input", nil, 2, nil},
+		{"fits in a single chunk", []string{"a", "b"}, 5, [][]string{{"a", "b"}}},
+		{"splits evenly", []string{"a", "b", "c", "d"}, 2, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"trailing partial chunk", []string{"a", "b", "c"}, 2, [][]string{{"a", "b"}, {"c"}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := chunkStrings(c.items, c.size)
+			if len(got) != len(c.want) {
+				t.Fatalf("chunkStrings(%v, %d) = %v, want %v", c.items, c.size, got, c.want)
+			}
+
+			for i := range got {
+				if len(got[i]) != len(c.want[i]) {
+					t.Fatalf("chunk %d = %v, want %v", i, got[i], c.want[i])
+				}
+
+				for j := range got[i] {
+					if got[i][j] != c.want[i][j] {
+						t.Errorf("chunk %d element %d = %q, want %q", i, j, got[i][j], c.want[i][j])
+					}
+				}
+			}
+		})
+	}
+}
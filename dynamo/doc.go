@@ -8,9 +8,30 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	dyna "github.com/aws/aws-sdk-go-v2/service/dynamodb/dynamodbattribute"
 	"github.com/docshelf/docshelf"
+	"github.com/eriktate/skribe/internal/logctx"
 	"github.com/pkg/errors"
 )
 
+// logStoreOp emits a structured event for a single store operation, with
+// enough fields (table, path, latency) to correlate it back to the HTTP
+// request that triggered it via the request_id/user_id already on ctx's
+// logger.
+func logStoreOp(ctx context.Context, op, table, path string, start time.Time, err error) {
+	logger := logctx.FromContext(ctx).With(
+		"op", op,
+		"table", table,
+		"path", path,
+		"latency_ms", time.Since(start).Milliseconds(),
+	)
+
+	if err != nil {
+		logger.Error("store operation failed", "error", err)
+		return
+	}
+
+	logger.Info("store operation")
+}
+
 // A Tag represents the dynamo data structure of a tag.
 type Tag struct {
 	Tag   string   `json:"tag"`
@@ -18,15 +39,24 @@ type Tag struct {
 }
 
 // GetDoc fetches a docshelf Document from dynamodb. It will also read and package the Content
-// form an underlying FileStore.
-func (s Store) GetDoc(ctx context.Context, path string) (docshelf.Doc, error) {
-	var doc docshelf.Doc
-
-	if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
+// form an underlying FileStore. A doc that's been RemoveDoc'd is reported as
+// not existing, the same as one that was never created - its row and
+// content are still on disk for PutDoc to resurrect or PurgeDoc to erase,
+// but GetDoc isn't the place to surface a tombstone to a caller.
+func (s Store) GetDoc(ctx context.Context, path string) (doc docshelf.Doc, err error) {
+	start := time.Now()
+	defer func() { logStoreOp(ctx, "GetDoc", s.docTable, path, start, err) }()
+
+	if err = s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
 		return doc, err
 	}
 
-	content, err := s.fs.ReadFile(path)
+	if doc.Deleted {
+		return docshelf.Doc{}, docshelf.ErrNotFound
+	}
+
+	var content []byte
+	content, err = s.fs.ReadFile(path)
 	if err != nil {
 		return doc, err
 	}
@@ -75,27 +105,18 @@ func (s Store) ListDocs(ctx context.Context, query string, tags ...string) ([]do
 }
 
 func (s Store) listDocs(ctx context.Context, paths []string) ([]docshelf.Doc, error) {
-	var docs []docshelf.Doc
-	for _, path := range paths {
-		var doc docshelf.Doc
-		if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
-			return nil, err
-		}
-
-		docs = append(docs, doc)
-	}
-
-	return docs, nil
+	return s.batchGetDocs(ctx, paths)
 }
 
 func (s Store) listTaggedDocs(ctx context.Context, tags []string) ([]docshelf.Doc, error) {
+	found, err := s.batchGetTags(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+
 	var paths []string
 	for _, t := range tags {
-		var tag Tag
-		if err := s.getItem(ctx, s.tagTable, "tag", t, &tag); err != nil {
-			return nil, err
-		}
-
+		tag := found[t]
 		if paths == nil {
 			paths = tag.Paths
 		} else {
@@ -103,38 +124,62 @@ func (s Store) listTaggedDocs(ctx context.Context, tags []string) ([]docshelf.Do
 		}
 	}
 
-	var docs []docshelf.Doc
-	for _, path := range paths {
-		var doc docshelf.Doc
-		if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
-			return nil, err
-		}
-
-		docs = append(docs, doc)
-	}
-
-	return docs, nil
+	return s.batchGetDocs(ctx, paths)
 }
 
 // PutDoc creates or updates an existing docshelf Doc in dynamodb. It will also store the
 // Content in an underlying FileStore.
-func (s Store) PutDoc(ctx context.Context, doc docshelf.Doc) error {
+//
+// Writes are optimistically concurrent: doc.UpdatedAt is treated as the
+// version the caller last read. A zero value means "create, and only if no
+// live row already exists" - a tombstoned row (see RemoveDoc) still counts
+// as not-live, so this is also how a delete gets undone; a non-zero value
+// means "update, and only if nobody else has updated it since". Either way,
+// a stale write fails with docshelf.ErrConflict instead of silently
+// clobbering a concurrent change.
+//
+// Content is also written to a content-addressed revision blob and appended
+// to doc.Revisions, unless the content is unchanged from the prior revision.
+// See GetDocRevision, ListRevisions, and DiffDocs for reading history back
+// out, and PurgeDoc for actually deleting it.
+func (s Store) PutDoc(ctx context.Context, doc docshelf.Doc) (err error) {
+	start := time.Now()
+	defer func() { logStoreOp(ctx, "PutDoc", s.docTable, doc.Path, start, err) }()
+
 	if doc.Path == "" {
 		return errors.New("can not create a new doc without a path")
 	}
 
-	if _, err := s.GetDoc(ctx, doc.Path); err != nil {
-		if !docshelf.CheckDoesNotExist(err) {
-			return errors.Wrap(err, "could not verify existing file")
+	expectedUpdatedAt := doc.UpdatedAt
+
+	// Look up the raw row rather than going through GetDoc, which reports a
+	// tombstoned doc as not-found - PutDoc is how a delete gets undone, so it
+	// needs the prior CreatedAt/Revisions even when Deleted is set.
+	var existing docshelf.Doc
+	getErr := s.getItem(ctx, s.docTable, "path", doc.Path, &existing)
+	if getErr != nil {
+		if !docshelf.CheckDoesNotExist(getErr) {
+			return errors.Wrap(getErr, "could not verify existing file")
 		}
 
 		doc.CreatedAt = time.Now()
+	} else {
+		doc.CreatedAt = existing.CreatedAt
+		doc.Revisions = existing.Revisions
 	}
 
 	doc.UpdatedAt = time.Now()
 
-	if err := s.fs.WriteFile(doc.Path, []byte(doc.Content)); err != nil {
-		return errors.Wrap(err, "failed to write doc to file store")
+	content := doc.Content
+	hash := hashContent(content)
+	newRevision := len(doc.Revisions) == 0 || doc.Revisions[len(doc.Revisions)-1].Hash != hash
+	if newRevision {
+		doc.Revisions = append(doc.Revisions, docshelf.Revision{
+			Hash:      hash,
+			Author:    doc.Author,
+			CreatedAt: doc.UpdatedAt,
+			Message:   doc.Message,
+		})
 	}
 
 	doc.Content = "" // need to clear content before storing doc
@@ -149,28 +194,83 @@ func (s Store) PutDoc(ctx context.Context, doc docshelf.Doc) error {
 		Item:      marshaled,
 	}
 
+	if expectedUpdatedAt.IsZero() {
+		// A zero UpdatedAt means "create, and only if no live row already
+		// exists" - but GetDoc hides a tombstoned row entirely, so a caller
+		// resurrecting a deleted path via a plain create has no UpdatedAt to
+		// echo back and will always hit this branch. Without the deleted
+		// clause, the row's continued presence (Deleted: true) would fail
+		// this condition and be reported as ErrConflict instead of letting
+		// the resurrection through.
+		deletedTrue, err := dyna.Marshal(true)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal deleted condition value")
+		}
+
+		input.ConditionExpression = aws.String("attribute_not_exists(#path) OR #deleted = :deletedTrue")
+		input.ExpressionAttributeNames = map[string]string{"#path": "path", "#deleted": "deleted"}
+		input.ExpressionAttributeValues = map[string]dynamodb.AttributeValue{
+			":deletedTrue": *deletedTrue,
+		}
+	} else {
+		expected, err := dyna.Marshal(expectedUpdatedAt)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal expected updatedAt")
+		}
+
+		input.ConditionExpression = aws.String("updatedAt = :expectedUpdatedAt")
+		input.ExpressionAttributeValues = map[string]dynamodb.AttributeValue{
+			":expectedUpdatedAt": *expected,
+		}
+	}
+
 	if _, err := s.client.PutItemRequest(&input).Send(); err != nil {
-		if err := s.fs.RemoveFile(doc.Path); err != nil { // need to rollback file storage if doc failes
-			return errors.Wrapf(err, "cleanup failed for file: %s", doc.Path)
+		if isConditionalCheckFailure(err) {
+			return docshelf.ErrConflict
 		}
 
 		return errors.Wrap(err, "failed to put doc into dynamo")
 	}
 
+	// Dynamo is the source of truth for who won the write; only persist
+	// content once it has. Writing the file store first could let a loser of
+	// the conditional PutItem above clobber the winner's content with no
+	// rollback path, since content isn't versioned the way the Dynamo row is.
+	if err := s.fs.WriteFile(doc.Path, []byte(content)); err != nil {
+		return errors.Wrap(err, "failed to write doc to file store")
+	}
+
+	if newRevision {
+		if err := s.fs.WriteFile(revisionBlobPath(doc.Path, hash), []byte(content)); err != nil {
+			return errors.Wrap(err, "failed to write revision blob to file store")
+		}
+	}
+
 	return nil
 }
 
-// TagDoc tags an existing document with the given tags.
-// TODO (erik): This is a mirror of the bolt implementation. Need to research and find out
-// if there's a more efficient way to get this behavior out of dynamo.
-func (s Store) TagDoc(ctx context.Context, path string, tags ...string) error {
+// TagDoc tags an existing document with the given tags. All tags are
+// written in a single TransactWriteItems call, so a failure partway through
+// (a conflicting concurrent tag, a throttled table) leaves no tags touched
+// rather than some.
+func (s Store) TagDoc(ctx context.Context, path string, tags ...string) (err error) {
+	start := time.Now()
+	defer func() { logStoreOp(ctx, "TagDoc", s.tagTable, path, start, err) }()
+
+	if len(tags) > batchWriteLimit {
+		return errors.Errorf("can not tag more than %d tags at once", batchWriteLimit)
+	}
+
+	existing, err := s.batchGetTags(ctx, tags)
+	if err != nil {
+		return errors.Wrap(err, "failed to load existing tags")
+	}
+
+	items := make([]dynamodb.TransactWriteItem, 0, len(tags))
 	for _, t := range tags {
-		var tag Tag
-		if err := s.getItem(ctx, s.tagTable, "tag", t, &tag); err != nil {
-			return err
-		}
+		tag := existing[t]
 
-		// short circuit if the tag alrady contains the path or no tag was returned.
+		// short circuit if the tag already contains the path.
 		if contains(tag.Paths, path) {
 			continue
 		}
@@ -182,40 +282,54 @@ func (s Store) TagDoc(ctx context.Context, path string, tags ...string) error {
 		tag.Paths = append(tag.Paths, path)
 		marshaled, err := dyna.MarshalMap(&tag)
 		if err != nil {
-			return err
+			return errors.Wrap(err, "failed to marshal tag")
 		}
 
-		input := dynamodb.PutItemInput{
-			TableName: aws.String(s.tagTable),
-			Item:      marshaled,
-		}
+		items = append(items, dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: aws.String(s.tagTable),
+				Item:      marshaled,
+			},
+		})
+	}
 
-		if _, err := s.client.PutItemRequest(&input).Send(); err != nil {
-			return err
-		}
+	if len(items) == 0 {
+		return nil
+	}
+
+	input := dynamodb.TransactWriteItemsInput{TransactItems: items}
+	if _, err := s.client.TransactWriteItemsRequest(&input).Send(); err != nil {
+		return errors.Wrap(err, "failed to transactionally write tags")
 	}
 
 	return nil
 }
 
-// RemoveDoc removes a docshelf Doc from dynamo as well as the underlying FileStore.
+// RemoveDoc marks a docshelf Doc as deleted rather than destroying it, so
+// its revision history stays intact for ListRevisions/GetDocRevision/
+// DiffDocs and the delete itself can be undone by a subsequent PutDoc. Use
+// PurgeDoc for a destructive, GDPR-style removal.
 func (s Store) RemoveDoc(ctx context.Context, path string) error {
-	if err := s.fs.RemoveFile(path); err != nil {
-		return errors.Wrap(err, "failed to remove doc from file store")
+	var doc docshelf.Doc
+	if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
+		return errors.Wrap(err, "failed to load doc")
 	}
 
-	key, err := makeKey("path", path)
+	doc.Deleted = true
+	doc.Content = ""
+
+	marshaled, err := dyna.MarshalMap(&doc)
 	if err != nil {
-		return errors.Wrap(err, "failed to make key")
+		return errors.Wrap(err, "failed to marshal doc for dynamo")
 	}
 
-	input := dynamodb.DeleteItemInput{
+	input := dynamodb.PutItemInput{
 		TableName: aws.String(s.docTable),
-		Key:       key,
+		Item:      marshaled,
 	}
 
-	if _, err := s.client.DeleteItemRequest(&input).Send(); err != nil {
-		return errors.Wrap(err, "failed to delete doc from dynamo")
+	if _, err := s.client.PutItemRequest(&input).Send(); err != nil {
+		return errors.Wrap(err, "failed to tombstone doc in dynamo")
 	}
 
 	return nil
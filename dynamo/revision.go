@@ -0,0 +1,145 @@
+package dynamo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/docshelf/docshelf"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// revisionBlobPath returns the content-addressed FileStore path a
+// revision's blob is written to. Blobs live alongside a doc's live content
+// rather than overwriting it, so history survives further edits.
+func revisionBlobPath(path, hash string) string {
+	return fmt.Sprintf(".revisions/%s/%s", path, hash)
+}
+
+// hashContent returns the sha256 hex digest PutDoc uses to address a
+// revision's blob.
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func hasRevision(revisions []docshelf.Revision, hash string) bool {
+	for _, r := range revisions {
+		if r.Hash == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetDocRevision fetches the content of a specific historical revision of
+// path by its content hash. The returned Doc carries the doc's current
+// metadata (including its full revision list) with Content set to that
+// revision's content.
+func (s Store) GetDocRevision(ctx context.Context, path, hash string) (doc docshelf.Doc, err error) {
+	start := time.Now()
+	defer func() { logStoreOp(ctx, "GetDocRevision", s.docTable, path, start, err) }()
+
+	if err = s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
+		return docshelf.Doc{}, err
+	}
+
+	if !hasRevision(doc.Revisions, hash) {
+		return docshelf.Doc{}, errors.Errorf("no revision %s for %s", hash, path)
+	}
+
+	var content []byte
+	content, err = s.fs.ReadFile(revisionBlobPath(path, hash))
+	if err != nil {
+		return docshelf.Doc{}, err
+	}
+
+	doc.Content = string(content)
+	return doc, nil
+}
+
+// ListRevisions returns the revision history for path, most recent first.
+func (s Store) ListRevisions(ctx context.Context, path string) ([]docshelf.Revision, error) {
+	var doc docshelf.Doc
+	if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]docshelf.Revision, len(doc.Revisions))
+	for i, rev := range doc.Revisions {
+		revisions[len(doc.Revisions)-1-i] = rev
+	}
+
+	return revisions, nil
+}
+
+// DiffDocs returns a unified diff between two revisions of path.
+func (s Store) DiffDocs(ctx context.Context, path, hashA, hashB string) (string, error) {
+	a, err := s.GetDocRevision(ctx, path, hashA)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load revision %s", hashA)
+	}
+
+	b, err := s.GetDocRevision(ctx, path, hashB)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to load revision %s", hashB)
+	}
+
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a.Content),
+		B:        difflib.SplitLines(b.Content),
+		FromFile: hashA,
+		ToFile:   hashB,
+		Context:  3,
+	}
+
+	var buf bytes.Buffer
+	if err := difflib.WriteUnifiedDiff(&buf, diff); err != nil {
+		return "", errors.Wrap(err, "failed to build diff")
+	}
+
+	return buf.String(), nil
+}
+
+// PurgeDoc permanently deletes a doc's Dynamo row, live content, and every
+// revision blob. Unlike RemoveDoc this can't be undone; it exists for
+// GDPR-style erasure requests and should only be reachable by admins.
+func (s Store) PurgeDoc(ctx context.Context, path string) error {
+	var doc docshelf.Doc
+	if err := s.getItem(ctx, s.docTable, "path", path, &doc); err != nil {
+		return errors.Wrap(err, "failed to load doc")
+	}
+
+	for _, rev := range doc.Revisions {
+		if err := s.fs.RemoveFile(revisionBlobPath(path, rev.Hash)); err != nil {
+			return errors.Wrapf(err, "failed to purge revision blob: %s", rev.Hash)
+		}
+	}
+
+	if err := s.fs.RemoveFile(path); err != nil {
+		return errors.Wrap(err, "failed to remove doc from file store")
+	}
+
+	key, err := makeKey("path", path)
+	if err != nil {
+		return errors.Wrap(err, "failed to make key")
+	}
+
+	input := dynamodb.DeleteItemInput{
+		TableName: aws.String(s.docTable),
+		Key:       key,
+	}
+
+	if _, err := s.client.DeleteItemRequest(&input).Send(); err != nil {
+		return errors.Wrap(err, "failed to delete doc from dynamo")
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package dynamo
+
+import (
+	"testing"
+
+	"github.com/docshelf/docshelf"
+)
+
+func TestHashContentIsStableAndContentAddressed(t *testing.T) {
+	a := hashContent("hello world")
+	b := hashContent("hello world")
+	c := hashContent("hello there")
+
+	if a != b {
+		t.Error("expected hashing the same content twice to produce the same hash")
+	}
+
+	if a == c {
+		t.Error("expected different content to produce different hashes")
+	}
+}
+
+func TestRevisionBlobPathIsScopedUnderRevisions(t *testing.T) {
+	got := revisionBlobPath("team/eng/doc1", "abc123")
+	want := ".revisions/team/eng/doc1/abc123"
+	if got != want {
+		t.Errorf("revisionBlobPath(...) = %q, want %q", got, want)
+	}
+}
+
+func TestHasRevision(t *testing.T) {
+	revisions := []docshelf.Revision{{Hash: "a"}, {Hash: "b"}}
+
+	if !hasRevision(revisions, "b") {
+		t.Error("expected hasRevision to find a hash present in the slice")
+	}
+
+	if hasRevision(revisions, "c") {
+		t.Error("expected hasRevision to report false for a hash not in the slice")
+	}
+}
@@ -0,0 +1,297 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/docshelf/docshelf"
+	"github.com/eriktate/skribe/internal/logctx"
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+// policyResourcePath is the synthetic path policy management is evaluated
+// against, since a docshelf.Policy isn't itself a doc with a real path.
+// Only a grant that matches it - in practice a "**" (or "/_policies/**")
+// admin policy - can manage policies; a grant scoped to e.g. "/team/eng/**"
+// gives admin over docs under that prefix, not over the PolicyStore.
+const policyResourcePath = "/_policies"
+
+// verbRank orders the verbs a policy can grant so a broader grant also
+// satisfies a narrower request, e.g. an "admin" policy also covers "read".
+var verbRank = map[string]int{"read": 1, "write": 2, "admin": 3}
+
+// validEffect reports whether effect is one of the values evaluatePolicies
+// actually understands. Anything else - empty, a typo, different casing -
+// must be rejected at write time rather than silently evaluating as an
+// implicit allow.
+func validEffect(effect string) bool {
+	return effect == "allow" || effect == "deny"
+}
+
+// validVerb reports whether verb is one evaluatePolicies knows how to rank.
+func validVerb(verb string) bool {
+	_, ok := verbRank[verb]
+	return ok
+}
+
+// verbForMethod maps an HTTP method to the verb it requires on a doc:
+// GET needs read, POST/PUT need write, and DELETE needs admin.
+func verbForMethod(method string) string {
+	switch method {
+	case http.MethodGet:
+		return "read"
+	case http.MethodPost, http.MethodPut:
+		return "write"
+	default:
+		return "admin"
+	}
+}
+
+// PolicyDecision records whether a single policy matched a request, for the
+// X-Skribe-Explain dry-run.
+type PolicyDecision struct {
+	Policy docshelf.Policy `json:"policy"`
+	Effect string          `json:"effect"`
+}
+
+// Authorization enforces docshelf.PolicyStore-backed access control on the
+// routes it wraps. It requires a single-doc route: one with a chi "id" URL
+// param (read/update/delete/tag/pin/...), or POST /doc/ (create), whose
+// target path instead lives in the request body - see docPathFromRequest.
+// A route with no single target doc, like GET /doc/list, can't be
+// evaluated by this middleware and must be excluded from the group it
+// wraps; filtering a list down to what its caller can read is a separate,
+// as-yet-unimplemented concern.
+//
+// The verb comes from the HTTP method (GET -> read, POST/PUT -> write,
+// DELETE -> admin), and every policy attached to the authenticated user or
+// one of their groups whose path pattern matches is evaluated. Path
+// patterns support globs: "*" matches a single path segment, "**" matches
+// any number of them.
+//
+// A request is allowed only if at least one matching policy allows it and
+// none deny it - deny always overrides allow, regardless of how many
+// policies matched or in what order they were evaluated.
+//
+// Sending "X-Skribe-Explain: 1" turns the request into a dry run: instead
+// of enforcing the decision, the matching policy chain is returned as JSON
+// and next is never called.
+func Authorization(policies docshelf.PolicyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, err := docPathFromRequest(r)
+			if err != nil {
+				badRequest(w, "could not determine target doc path")
+				return
+			}
+
+			if authorize(w, r, policies, path, verbForMethod(r.Method)) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// requireAdmin gates the routes it wraps behind an admin-verb policy
+// matching policyResourcePath, so policy management is protected by the
+// same PolicyStore it configures rather than merely requiring a valid
+// session. Without this, any authenticated user could grant themselves
+// admin over every doc by POSTing a policy naming themselves as subject.
+func requireAdmin(policies docshelf.PolicyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authorize(w, r, policies, policyResourcePath, "admin") {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// authorize resolves the authenticated user and evaluates policies for verb
+// on path, writing the appropriate response and returning false if the
+// caller shouldn't proceed. "X-Skribe-Explain: 1" turns it into a dry run:
+// the matching policy chain is returned as JSON and the caller never
+// proceeds, regardless of the actual decision.
+func authorize(w http.ResponseWriter, r *http.Request, policies docshelf.PolicyStore, path, verb string) bool {
+	user, err := getContextUser(r.Context())
+	if err != nil {
+		unauthorized(w, "no authenticated user")
+		return false
+	}
+
+	subjects := append([]string{user.ID}, user.Groups...)
+
+	all, err := policies.ListPolicies(r.Context())
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to load policies", "error", err)
+		serverError(w, "failed to evaluate authorization")
+		return false
+	}
+
+	allowed, chain := evaluatePolicies(all, subjects, path, verb)
+
+	if r.Header.Get("X-Skribe-Explain") == "1" {
+		respondJSON(w, chain)
+		return false
+	}
+
+	if !allowed {
+		unauthorized(w, "not authorized")
+		return false
+	}
+
+	return true
+}
+
+// docPathFromRequest resolves the doc path a request targets. Routes with
+// an {id} segment (read/update/delete/tag/pin/...) take it from there; doc
+// creation (POST /doc/) has no such segment; the path instead lives in the
+// JSON request body, so this peeks it and restores the body for the real
+// handler to decode again.
+func docPathFromRequest(r *http.Request) (string, error) {
+	if id := chi.URLParam(r, "id"); id != "" {
+		return id, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read request body")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var target struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(body, &target); err != nil {
+		return "", errors.Wrap(err, "failed to parse request body")
+	}
+
+	return target.Path, nil
+}
+
+// evaluatePolicies returns whether verb on path is allowed for subjects
+// under deny-overrides semantics, along with the chain of policies that
+// matched (for the explain dry-run).
+func evaluatePolicies(all []docshelf.Policy, subjects []string, path, verb string) (bool, []PolicyDecision) {
+	var chain []PolicyDecision
+	allowed := false
+	denied := false
+
+	for _, p := range all {
+		if !policyApplies(p, subjects, path, verb) {
+			continue
+		}
+
+		chain = append(chain, PolicyDecision{Policy: p, Effect: p.Effect})
+		switch p.Effect {
+		case "allow":
+			allowed = true
+		case "deny":
+			denied = true
+		}
+	}
+
+	return allowed && !denied, chain
+}
+
+// policyApplies reports whether p grants or denies verb on path for one of
+// subjects.
+func policyApplies(p docshelf.Policy, subjects []string, path, verb string) bool {
+	subjectMatch := false
+	for _, s := range subjects {
+		if s == p.Subject {
+			subjectMatch = true
+			break
+		}
+	}
+
+	if !subjectMatch {
+		return false
+	}
+
+	if !pathMatches(p.Path, path) {
+		return false
+	}
+
+	return verbRank[p.Verb] >= verbRank[verb]
+}
+
+// pathMatches reports whether a policy's glob pattern matches path.
+func pathMatches(pattern, path string) bool {
+	re, err := globToRegexp(pattern)
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(path)
+}
+
+// globToRegexp compiles a policy path pattern into a regexp. "**" matches
+// any number of path segments (including none); "*" matches exactly one
+// segment's worth of non-slash characters.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '/' && strings.HasPrefix(pattern[i+1:], "**") {
+			b.WriteString("(/.*)?")
+			i += 2
+			continue
+		}
+
+		if pattern[i] == '*' {
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				continue
+			}
+
+			b.WriteString("[^/]*")
+			continue
+		}
+
+		b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// WhoCan returns the subject IDs (user or group) holding a policy that
+// allows verb on path, for audit purposes. It only considers allow
+// policies in isolation - a subject it returns could still be denied in
+// practice by a more specific deny policy evaluated alongside a different
+// subject's grant, so treat the result as "who might be able to", not a
+// guarantee.
+func WhoCan(ctx context.Context, policies docshelf.PolicyStore, path, verb string) ([]string, error) {
+	all, err := policies.ListPolicies(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load policies")
+	}
+
+	var subjects []string
+	for _, p := range all {
+		if p.Effect != "allow" {
+			continue
+		}
+
+		if !pathMatches(p.Path, path) {
+			continue
+		}
+
+		if verbRank[p.Verb] < verbRank[verb] {
+			continue
+		}
+
+		subjects = append(subjects, p.Subject)
+	}
+
+	return subjects, nil
+}
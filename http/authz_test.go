@@ -0,0 +1,135 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/docshelf/docshelf"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"exact match", "/team/eng/doc1", "/team/eng/doc1", true},
+		{"exact mismatch", "/team/eng/doc1", "/team/eng/doc2", false},
+		{"single segment wildcard matches one segment", "/team/*/doc1", "/team/eng/doc1", true},
+		{"single segment wildcard does not cross slash", "/team/*/doc1", "/team/eng/sub/doc1", false},
+		{"double star matches any depth", "/team/**", "/team/eng/sub/doc1", true},
+		{"double star matches zero segments", "/team/**", "/team", true},
+		{"bare double star matches everything", "**", "/anything/at/all", true},
+		{"pattern is anchored, not a prefix match", "/team/eng", "/team/eng/doc1", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			re, err := globToRegexp(c.pattern)
+			if err != nil {
+				t.Fatalf("globToRegexp(%q) returned error: %v", c.pattern, err)
+			}
+
+			if got := re.MatchString(c.path); got != c.want {
+				t.Errorf("globToRegexp(%q).MatchString(%q) = %v, want %v", c.pattern, c.path, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPolicyApplies(t *testing.T) {
+	policy := docshelf.Policy{Subject: "alice", Path: "/team/eng/**", Verb: "write", Effect: "allow"}
+
+	cases := []struct {
+		name     string
+		subjects []string
+		path     string
+		verb     string
+		want     bool
+	}{
+		{"matching subject, path, and verb", []string{"alice"}, "/team/eng/doc1", "write", true},
+		{"matching subject via group membership", []string{"bob", "alice"}, "/team/eng/doc1", "read", true},
+		{"broader grant covers a narrower verb", []string{"alice"}, "/team/eng/doc1", "read", true},
+		{"narrower grant does not cover a broader verb", []string{"alice"}, "/team/eng/doc1", "admin", false},
+		{"subject does not match", []string{"bob"}, "/team/eng/doc1", "read", false},
+		{"path does not match", []string{"alice"}, "/team/other/doc1", "read", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := policyApplies(policy, c.subjects, c.path, c.verb); got != c.want {
+				t.Errorf("policyApplies(...) = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePoliciesDenyOverridesAllow(t *testing.T) {
+	all := []docshelf.Policy{
+		{Subject: "alice", Path: "/team/eng/**", Verb: "admin", Effect: "allow"},
+		{Subject: "alice", Path: "/team/eng/secret", Verb: "read", Effect: "deny"},
+	}
+
+	allowed, chain := evaluatePolicies(all, []string{"alice"}, "/team/eng/secret", "read")
+	if allowed {
+		t.Error("expected a matching deny policy to override a broader allow grant")
+	}
+
+	if len(chain) != 2 {
+		t.Errorf("expected both matching policies in the explain chain, got %d", len(chain))
+	}
+}
+
+func TestEvaluatePoliciesRequiresAnAllow(t *testing.T) {
+	all := []docshelf.Policy{
+		{Subject: "alice", Path: "/team/other/**", Verb: "admin", Effect: "allow"},
+	}
+
+	if allowed, _ := evaluatePolicies(all, []string{"alice"}, "/team/eng/doc1", "read"); allowed {
+		t.Error("expected no matching policy to deny the request")
+	}
+}
+
+func TestEvaluatePoliciesDoesNotFailOpenOnUnknownEffect(t *testing.T) {
+	cases := []string{"", "Deny", "DENY", "allowed", "typo"}
+
+	for _, effect := range cases {
+		t.Run(effect, func(t *testing.T) {
+			all := []docshelf.Policy{
+				{Subject: "alice", Path: "**", Verb: "admin", Effect: effect},
+			}
+
+			if allowed, _ := evaluatePolicies(all, []string{"alice"}, "/team/eng/doc1", "read"); allowed {
+				t.Errorf("expected a policy with Effect %q to not grant access", effect)
+			}
+		})
+	}
+}
+
+func TestValidEffect(t *testing.T) {
+	for _, effect := range []string{"allow", "deny"} {
+		if !validEffect(effect) {
+			t.Errorf("expected %q to be a valid effect", effect)
+		}
+	}
+
+	for _, effect := range []string{"", "Allow", "DENY", "typo"} {
+		if validEffect(effect) {
+			t.Errorf("expected %q to not be a valid effect", effect)
+		}
+	}
+}
+
+func TestValidVerb(t *testing.T) {
+	for _, verb := range []string{"read", "write", "admin"} {
+		if !validVerb(verb) {
+			t.Errorf("expected %q to be a valid verb", verb)
+		}
+	}
+
+	for _, verb := range []string{"", "Read", "delete"} {
+		if validVerb(verb) {
+			t.Errorf("expected %q to not be a valid verb", verb)
+		}
+	}
+}
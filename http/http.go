@@ -3,47 +3,71 @@ package http
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/docshelf/docshelf"
+	"github.com/eriktate/skribe/connector"
+	"github.com/eriktate/skribe/internal/logctx"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
-	"github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
 )
 
 // A Server is a collection of stores that get wired up to HTTP endpoint.
 type Server struct {
-	host           string
-	port           uint
-	log            *logrus.Logger
-	authenticators map[string]docshelf.Authenticator
+	host        string
+	port        uint
+	log         *slog.Logger
+	connectors  *connector.Registry
+	tokenIssuer TokenIssuer
+	pkce        *pkceStore
+
+	DocHandler    DocHandler
+	UserStore     docshelf.UserStore
+	GroupStore    docshelf.GroupStore
+	PolicyStore   docshelf.PolicyStore
+	RevisionStore RevisionStore
+}
 
-	DocHandler  DocHandler
-	UserStore   docshelf.UserStore
-	GroupStore  docshelf.GroupStore
-	PolicyStore docshelf.PolicyStore
+// A RevisionStore reads back the history PutDoc builds up for a doc and
+// can permanently erase it. dynamo.Store satisfies this.
+type RevisionStore interface {
+	ListRevisions(ctx context.Context, path string) ([]docshelf.Revision, error)
+	GetDocRevision(ctx context.Context, path, hash string) (docshelf.Doc, error)
+	DiffDocs(ctx context.Context, path, hashA, hashB string) (string, error)
+	PurgeDoc(ctx context.Context, path string) error
 }
 
 // NewServer returns a new Server struct.
-func NewServer(host string, port uint, logger *logrus.Logger) Server {
+func NewServer(host string, port uint, logger *slog.Logger) Server {
 	return Server{
-		host:           host,
-		port:           port,
-		log:            logger,
-		authenticators: make(map[string]docshelf.Authenticator),
+		host:       host,
+		port:       port,
+		log:        logger,
+		connectors: connector.NewRegistry(),
+		pkce:       newPKCEStore(),
 	}
 }
 
-// AddAuth method to server.
-func (s Server) AddAuth(name string, auth docshelf.Authenticator) {
-	s.authenticators[name] = auth
+// AddConnector registers a connector under name, making it reachable at
+// /auth/{name}/login and /auth/{name}/callback.
+func (s Server) AddConnector(name string, c connector.Connector) {
+	s.connectors.Register(name, c)
+}
+
+// SetTokenIssuer wires a TokenIssuer into the server. Until one is set,
+// login and oauth fall back to the legacy session cookie with no bearer
+// token issued.
+func (s *Server) SetTokenIssuer(issuer TokenIssuer) {
+	s.tokenIssuer = issuer
 }
 
 // Start fires up an HTTP server and listens for incoming requests.
 func (s Server) Start() error {
-	s.log.WithField("host", s.host).WithField("port", s.port).Info("server starting")
+	s.log.Info("server starting", "host", s.host, "port", s.port)
 	// if err := s.CheckStores(); err != nil {
 	// 	return err
 	// }
@@ -69,8 +93,8 @@ func (s Server) CheckHandlers() error {
 		return errors.New("no PolicyStore set")
 	}
 
-	if len(s.authenticators) == 0 {
-		return errors.New("no Authenticator set")
+	if s.connectors.Len() == 0 {
+		return errors.New("no connectors registered")
 	}
 
 	return nil
@@ -89,8 +113,9 @@ func (s Server) buildRoutes() chi.Router {
 
 	userHandler := NewUserHandler(s.UserStore, s.log)
 	router.Use(cors.Handler)
+	router.Use(requestLogger(s.log))
 	router.Route("/api", func(r chi.Router) {
-		r.Use(Authentication(s.UserStore))
+		r.Use(s.authenticate)
 		r.Route("/user", func(r chi.Router) {
 			r.Get("/", userHandler.GetCurrentUser)
 			r.Get("/list", userHandler.GetUsers)
@@ -100,19 +125,44 @@ func (s Server) buildRoutes() chi.Router {
 		})
 
 		r.Route("/doc", func(r chi.Router) {
-			r.Post("/", s.DocHandler.PostDoc)
+			// GET /list has no single target doc path for Authorization to
+			// evaluate against, so it's left out of the group below. It's
+			// already filtered to tagged/indexed docs by ListDocs; per-doc
+			// filtering of those results against the caller's policies is a
+			// separate, as-yet-unimplemented concern.
 			r.Get("/list", s.DocHandler.GetList)
-			r.Post("/{id}/pin", s.DocHandler.PinDoc)
-			r.Post("/{id}/tag", s.DocHandler.PostTag)
-			r.Get("/{id}", s.DocHandler.GetDoc)
-			r.Delete("/{id}", s.DocHandler.DeleteDoc)
+
+			r.Group(func(r chi.Router) {
+				r.Use(Authorization(s.PolicyStore))
+				r.Post("/", s.DocHandler.PostDoc)
+				r.Post("/{id}/pin", s.DocHandler.PinDoc)
+				r.Post("/{id}/tag", s.DocHandler.PostTag)
+				r.Get("/{id}", s.DocHandler.GetDoc)
+				r.Delete("/{id}", s.DocHandler.DeleteDoc)
+				r.Get("/{id}/revisions", s.handleListRevisions)
+				r.Get("/{id}/diff", s.handleDiffDoc)
+				r.Delete("/{id}/purge", s.handlePurgeDoc)
+			})
+		})
+
+		r.Route("/policy", func(r chi.Router) {
+			r.Use(requireAdmin(s.PolicyStore))
+			r.Get("/", s.handleListPolicies)
+			r.Post("/", s.handleCreatePolicy)
+			r.Get("/who-can", s.handleWhoCan)
+			r.Get("/{id}", s.handleGetPolicy)
+			r.Delete("/{id}", s.handleDeletePolicy)
 		})
 	})
 
 	router.Get("/doc/{path}", s.DocHandler.RenderDoc)
-	router.Post("/login", s.handleLogin)
 	router.Get("/logout", handleLogout)
-	router.Get("/oauth/{provider}", s.handleOauth)
+	router.Get("/auth/connectors", s.handleConnectorList)
+	router.Get("/auth/{connector}/login", s.handleConnectorLogin)
+	router.Post("/auth/{connector}/login", s.handleConnectorLogin)
+	router.Get("/auth/{connector}/callback", s.handleConnectorCallback)
+	router.Post("/auth/refresh", s.handleRefresh)
+	router.Get("/.well-known/jwks.json", s.handleJWKS)
 
 	// router.Handle("/*", http.FileServer(http.Dir("./ui/dist/")))
 	router.Handle("/*", http.HandlerFunc(s.handleDefault))
@@ -121,68 +171,252 @@ func (s Server) buildRoutes() chi.Router {
 }
 
 func (s Server) handleDefault(w http.ResponseWriter, r *http.Request) {
-	s.log.WithField("url", r.URL.String()).Info("handling unkown request")
+	logctx.FromContext(r.Context()).Info("handling unkown request", "url", r.URL.String())
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+
+	// TODO (erik): This is a hack to make it easy to have "auth" during dev. This is *NOT* secure, by any means :D
+	identity := http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+	}
+
+	http.SetCookie(w, &identity)
+	// need to force a refresh so the app figures the user is invalid
+	redirect(w, "http://localhost:9001")
 }
 
-func (s Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	var login docshelf.User
-	s.log.Info("handling login")
-	if err := json.NewDecoder(r.Body).Decode(&login); err != nil {
-		s.log.Error(err)
-		badRequest(w, "invalid authentication data")
+// handleConnectorList serves discovery metadata for every registered
+// connector so the UI can render an appropriate login option for each.
+func (s Server) handleConnectorList(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, s.connectors.List())
+}
+
+// handleConnectorLogin starts a connector's authentication flow. For
+// redirect-based connectors (OIDC, SAML, GitHub) it stashes a PKCE verifier
+// and redirects the browser upstream; for direct-bind connectors (LDAP,
+// Keystone) it authenticates immediately from the request body and
+// completes the session in place.
+func (s Server) handleConnectorLogin(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "connector")
+	conn, ok := s.connectors.Get(name)
+	if !ok {
+		notFound(w, "unknown connector")
 		return
 	}
 
-	provider := "basic"
-	if login.Email == "" {
-		provider = "google"
+	state, challenge, err := s.pkce.Start(name)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to start pkce flow", "error", err)
+		serverError(w, "authentication failed")
+		return
 	}
 
-	user, err := s.authenticators[provider].Authenticate(r.Context(), login.Email, login.Token)
+	redirectURL, result, err := conn.Login(r.Context(), r, state, challenge)
 	if err != nil {
-		s.log.Error(err)
+		logctx.FromContext(r.Context()).Error("failed to start login", "error", err, "connector", name)
 		unauthorized(w, "invalid credentials")
 		return
 	}
 
-	// TODO (erik): This is a hack to make it easy to have "auth" during dev. This is *NOT* secure, by any means :D
-	identity := http.Cookie{
-		Name:     "session",
-		Value:    user.ID,
-		Path:     "/",
-		HttpOnly: true,
+	if result != nil {
+		s.completeConnectorAuthentication(w, r, *result)
+		return
 	}
 
-	http.SetCookie(w, &identity)
-	noContent(w)
+	redirect(w, redirectURL)
 }
 
-func handleLogout(w http.ResponseWriter, r *http.Request) {
+// handleConnectorCallback completes a redirect-based connector's flow and
+// issues a skribe session for the resulting identity.
+func (s Server) handleConnectorCallback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "connector")
+	logctx.FromContext(r.Context()).Info("handling connector callback", "connector", name)
 
-	// TODO (erik): This is a hack to make it easy to have "auth" during dev. This is *NOT* secure, by any means :D
-	identity := http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
+	conn, ok := s.connectors.Get(name)
+	if !ok {
+		notFound(w, "unknown connector")
+		return
 	}
 
-	http.SetCookie(w, &identity)
-	// need to force a refresh so the app figures the user is invalid
-	redirect(w, "http://localhost:9001")
+	verifier, err := s.pkce.Redeem(name, r.URL.Query().Get("state"))
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("rejected callback with bad state", "error", err, "connector", name)
+		unauthorized(w, "invalid state")
+		return
+	}
+
+	result, err := conn.HandleCallback(r.Context(), r, verifier)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to complete callback", "error", err, "connector", name)
+		serverError(w, "authentication failed")
+		return
+	}
+
+	s.completeConnectorAuthentication(w, r, result)
 }
 
-func (s Server) handleOauth(w http.ResponseWriter, r *http.Request) {
-	provider := chi.URLParam(r, "provider")
-	code := r.URL.Query().Get("code")
-	s.log.WithField("provider", provider).Info("handling oauth")
-	user, err := s.authenticators[provider].Authenticate(r.Context(), "", code)
+// completeConnectorAuthentication reconciles a connector.Result against the
+// UserStore and finishes the session the same way a direct login would.
+func (s Server) completeConnectorAuthentication(w http.ResponseWriter, r *http.Request, result connector.Result) {
+	user, err := s.resolveUser(r.Context(), result)
 	if err != nil {
-		s.log.WithError(err).WithField("provider", provider).Error("failed to authenticate with provider")
+		logctx.FromContext(r.Context()).Error("failed to resolve user from connector result", "error", err)
 		serverError(w, "authentication failed")
 		return
 	}
 
+	s.completeAuthentication(r.Context(), w, user)
+}
+
+// resolveUser reconciles a connector.Result against the UserStore, creating
+// the user on first login and refreshing their upstream IdentityToken and
+// groups on subsequent ones. The caller uses the returned ID as the session
+// cookie value and JWT subject, so a brand-new user is assigned one here
+// rather than left zero-valued - PutUser's signature doesn't report back
+// whatever ID the store might otherwise have assigned.
+func (s Server) resolveUser(ctx context.Context, result connector.Result) (docshelf.User, error) {
+	user, err := s.UserStore.GetUserByEmail(ctx, result.Email)
+	if err != nil {
+		if !docshelf.CheckDoesNotExist(err) {
+			return docshelf.User{}, errors.Wrap(err, "failed to look up user")
+		}
+
+		id, err := randomToken()
+		if err != nil {
+			return docshelf.User{}, errors.Wrap(err, "failed to generate user id")
+		}
+
+		user = docshelf.User{ID: id, Email: result.Email}
+	}
+
+	user.Groups = result.Groups
+	user.IdentityToken = result.IdentityToken
+
+	if err := s.UserStore.PutUser(ctx, user); err != nil {
+		return docshelf.User{}, errors.Wrap(err, "failed to store user")
+	}
+
+	return user, nil
+}
+
+// handleRefresh exchanges a refresh token for a new access/refresh pair,
+// rotating the refresh token in the process.
+func (s Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if s.tokenIssuer == nil {
+		notFound(w, "token refresh is not enabled")
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, "invalid refresh request")
+		return
+	}
+
+	token, err := s.tokenIssuer.Refresh(r.Context(), body.RefreshToken)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to refresh token", "error", err)
+		unauthorized(w, "invalid refresh token")
+		return
+	}
+
+	respondJSON(w, token)
+}
+
+// handleJWKS serves the issuer's current public keys so other services can
+// verify skribe-issued tokens without calling back into skribe.
+func (s Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if s.tokenIssuer == nil {
+		respondJSON(w, JWKS{Keys: []JWK{}})
+		return
+	}
+
+	keys, err := s.tokenIssuer.JWKS(r.Context())
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to load jwks", "error", err)
+		serverError(w, "failed to load keys")
+		return
+	}
+
+	respondJSON(w, keys)
+}
+
+// handleListRevisions returns the revision history for a doc, most recent
+// first.
+func (s Server) handleListRevisions(w http.ResponseWriter, r *http.Request) {
+	if s.RevisionStore == nil {
+		notFound(w, "revision history is not enabled")
+		return
+	}
+
+	path := chi.URLParam(r, "id")
+	revisions, err := s.RevisionStore.ListRevisions(r.Context(), path)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to list revisions", "error", err, "path", path)
+		serverError(w, "failed to list revisions")
+		return
+	}
+
+	respondJSON(w, revisions)
+}
+
+// handleDiffDoc returns a unified diff between the revisions identified by
+// the "a" and "b" query parameters.
+func (s Server) handleDiffDoc(w http.ResponseWriter, r *http.Request) {
+	if s.RevisionStore == nil {
+		notFound(w, "revision history is not enabled")
+		return
+	}
+
+	path := chi.URLParam(r, "id")
+	hashA := r.URL.Query().Get("a")
+	hashB := r.URL.Query().Get("b")
+	if hashA == "" || hashB == "" {
+		badRequest(w, "both a and b revision hashes are required")
+		return
+	}
+
+	diff, err := s.RevisionStore.DiffDocs(r.Context(), path, hashA, hashB)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to diff revisions", "error", err, "path", path)
+		serverError(w, "failed to diff revisions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(diff))
+}
+
+// handlePurgeDoc permanently deletes a doc and its entire revision history.
+// Unlike DocHandler.DeleteDoc (a tombstone), this cannot be undone.
+func (s Server) handlePurgeDoc(w http.ResponseWriter, r *http.Request) {
+	if s.RevisionStore == nil {
+		notFound(w, "revision history is not enabled")
+		return
+	}
+
+	path := chi.URLParam(r, "id")
+	if err := s.RevisionStore.PurgeDoc(r.Context(), path); err != nil {
+		logctx.FromContext(r.Context()).Error("failed to purge doc", "error", err, "path", path)
+		serverError(w, "failed to purge doc")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// completeAuthentication finishes a successful login or oauth exchange: it
+// sets the legacy session cookie for browser flows and, when a TokenIssuer
+// is configured, also issues and returns a bearer token pair.
+func (s Server) completeAuthentication(ctx context.Context, w http.ResponseWriter, user docshelf.User) {
+	// TODO (erik): The cookie is a hack to make it easy to have "auth" during dev. This is *NOT* secure, by any means :D
 	identity := http.Cookie{
 		Name:     "session",
 		Value:    user.ID,
@@ -191,7 +425,69 @@ func (s Server) handleOauth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.SetCookie(w, &identity)
-	redirect(w, "http://localhost:9001")
+
+	if s.tokenIssuer == nil {
+		noContent(w)
+		return
+	}
+
+	token, err := s.tokenIssuer.Issue(ctx, user)
+	if err != nil {
+		logctx.FromContext(ctx).Error("failed to issue token", "error", err)
+		serverError(w, "authentication failed")
+		return
+	}
+
+	respondJSON(w, token)
+}
+
+// authenticate resolves the requesting user from a bearer token when a
+// TokenIssuer is configured, falling back to the legacy session cookie for
+// browser flows that haven't moved to bearer tokens yet. Either way, it
+// stamps the resolved user_id onto the request-scoped logger so every log
+// line from here on, including ones store operations emit, can be
+// correlated back to this request.
+func (s Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tokenIssuer != nil {
+			if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+				claims, err := s.tokenIssuer.Validate(r.Context(), strings.TrimPrefix(header, "Bearer "))
+				if err != nil {
+					logctx.FromContext(r.Context()).Error("rejected bearer token", "error", err)
+					unauthorized(w, "invalid token")
+					return
+				}
+
+				ctx := s.withAuthenticatedUser(r.Context(), docshelf.User{ID: claims.Subject, Groups: claims.Skribe.Groups})
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value == "" {
+			unauthorized(w, "no session present")
+			return
+		}
+
+		user, err := s.UserStore.GetUser(r.Context(), cookie.Value)
+		if err != nil {
+			logctx.FromContext(r.Context()).Error("rejected session cookie", "error", err)
+			unauthorized(w, "invalid session")
+			return
+		}
+
+		ctx := s.withAuthenticatedUser(r.Context(), user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withAuthenticatedUser attaches user to ctx both as the resolved request
+// user and as a user_id field on the request-scoped logger.
+func (s Server) withAuthenticatedUser(ctx context.Context, user docshelf.User) context.Context {
+	ctx = context.WithValue(ctx, userKey, user)
+	logger := logctx.FromContext(ctx).With("user_id", user.ID)
+	return logctx.WithLogger(ctx, logger)
 }
 
 // everything down here is setup for attaching certain data to the request context.
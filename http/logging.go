@@ -0,0 +1,82 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/eriktate/skribe/internal/logctx"
+	"github.com/go-chi/chi"
+)
+
+// statusRecorder wraps a ResponseWriter so requestLogger can report the
+// status a handler sent without every handler having to report it
+// explicitly. Like net/http, a status is assumed to be 200 if the handler
+// never calls WriteHeader itself.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// requestLogger stamps every request with a request_id and remote_ip,
+// attaches the resulting logger to the request context via internal/logctx,
+// and logs a single structured event once the request completes, including
+// the matched chi route pattern and response status. Handlers that resolve
+// a user (see Server.authenticate) attach a logger with user_id to the
+// context they pass on, so it appears on the log lines emitted further down
+// the chain, including the ones store operations emit. The completion event
+// logged here uses the logger built before authentication and carries no
+// user_id.
+func requestLogger(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := newRequestID()
+
+			logger := base.With(
+				"request_id", requestID,
+				"remote_ip", r.RemoteAddr,
+			)
+
+			ctx := logctx.WithLogger(r.Context(), logger)
+			w.Header().Set("X-Request-Id", requestID)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			// RoutePattern is only populated once chi finishes routing the
+			// request, so it's read after ServeHTTP returns rather than
+			// added to the base logger above. Using the pattern (e.g.
+			// "/api/doc/{id}") instead of r.URL.Path keeps every request
+			// against a given route aggregated under one log value instead
+			// of one per concrete path.
+			route := chi.RouteContext(r.Context()).RoutePattern()
+			if route == "" {
+				route = r.URL.Path
+			}
+
+			logger.Info("handled request",
+				"method", r.Method,
+				"route", route,
+				"status", rec.status,
+				"latency_ms", time.Since(start).Milliseconds(),
+			)
+		})
+	}
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(buf)
+}
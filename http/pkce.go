@@ -0,0 +1,83 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pkceTTL is how long a state/verifier pair survives before the
+// authorization round trip is considered abandoned.
+const pkceTTL = 10 * time.Minute
+
+// pkceEntry is the verifier stashed server-side for a single in-flight
+// authorization code exchange, keyed by the opaque state value we send to
+// the provider.
+type pkceEntry struct {
+	verifier  string
+	provider  string
+	expiresAt time.Time
+}
+
+// pkceStore hands out state/challenge pairs for the authorization code flow
+// and redeems them exactly once during the callback. It's in-memory because
+// entries are short-lived and scoped to a single skribe instance; a
+// multi-node deployment would back this with the same store used for
+// refresh tokens.
+type pkceStore struct {
+	mu      sync.Mutex
+	entries map[string]pkceEntry
+}
+
+func newPKCEStore() *pkceStore {
+	return &pkceStore{entries: make(map[string]pkceEntry)}
+}
+
+// Start generates a new state/verifier/challenge triple for provider and
+// remembers the verifier under state.
+func (s *pkceStore) Start(provider string) (state, challenge string, err error) {
+	state, err = randomToken()
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate state")
+	}
+
+	verifier, err := randomToken()
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to generate verifier")
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	s.entries[state] = pkceEntry{verifier: verifier, provider: provider, expiresAt: time.Now().Add(pkceTTL)}
+	s.mu.Unlock()
+
+	return state, challenge, nil
+}
+
+// Redeem looks up and removes the verifier for state, failing if it's
+// missing, expired, or was issued for a different provider.
+func (s *pkceStore) Redeem(provider, state string) (verifier string, err error) {
+	s.mu.Lock()
+	entry, ok := s.entries[state]
+	delete(s.entries, state)
+	s.mu.Unlock()
+
+	if !ok {
+		return "", errors.New("unknown or already-used state")
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		return "", errors.New("state expired")
+	}
+
+	if entry.provider != provider {
+		return "", errors.New("state does not match provider")
+	}
+
+	return entry.verifier, nil
+}
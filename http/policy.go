@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/docshelf/docshelf"
+	"github.com/eriktate/skribe/internal/logctx"
+	"github.com/go-chi/chi"
+)
+
+// handleListPolicies returns every policy in the PolicyStore.
+func (s Server) handleListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := s.PolicyStore.ListPolicies(r.Context())
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to list policies", "error", err)
+		serverError(w, "failed to list policies")
+		return
+	}
+
+	respondJSON(w, policies)
+}
+
+// handleGetPolicy returns a single policy by ID.
+func (s Server) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	policy, err := s.PolicyStore.GetPolicy(r.Context(), id)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to get policy", "error", err, "id", id)
+		notFound(w, "policy not found")
+		return
+	}
+
+	respondJSON(w, policy)
+}
+
+// handleCreatePolicy creates or replaces a policy.
+func (s Server) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var policy docshelf.Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		badRequest(w, "invalid policy")
+		return
+	}
+
+	if !validEffect(policy.Effect) {
+		badRequest(w, `effect must be "allow" or "deny"`)
+		return
+	}
+
+	if !validVerb(policy.Verb) {
+		badRequest(w, "verb must be one of read, write, admin")
+		return
+	}
+
+	saved, err := s.PolicyStore.PutPolicy(r.Context(), policy)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to put policy", "error", err)
+		serverError(w, "failed to save policy")
+		return
+	}
+
+	respondJSON(w, saved)
+}
+
+// handleDeletePolicy removes a policy by ID.
+func (s Server) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := s.PolicyStore.DeletePolicy(r.Context(), id); err != nil {
+		logctx.FromContext(r.Context()).Error("failed to delete policy", "error", err, "id", id)
+		serverError(w, "failed to delete policy")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWhoCan answers "who can {verb} on {path}" for audit purposes, via
+// the ?path= and ?verb= query params.
+func (s Server) handleWhoCan(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	verb := r.URL.Query().Get("verb")
+	if path == "" || verb == "" {
+		badRequest(w, "both path and verb are required")
+		return
+	}
+
+	subjects, err := WhoCan(r.Context(), s.PolicyStore, path, verb)
+	if err != nil {
+		logctx.FromContext(r.Context()).Error("failed to evaluate who-can", "error", err, "path", path, "verb", verb)
+		serverError(w, "failed to evaluate who-can")
+		return
+	}
+
+	respondJSON(w, subjects)
+}
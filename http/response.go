@@ -0,0 +1,39 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// notFound writes a 404 with a plain-text message, matching the other
+// status helpers used across the package.
+func notFound(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(msg))
+}
+
+// respondJSON writes v to w as a 200 JSON response.
+func respondJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		serverError(w, "failed to encode response")
+	}
+}
+
+// badRequest writes a 400 with a plain-text message.
+func badRequest(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(msg))
+}
+
+// unauthorized writes a 401 with a plain-text message.
+func unauthorized(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(msg))
+}
+
+// serverError writes a 500 with a plain-text message.
+func serverError(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write([]byte(msg))
+}
@@ -0,0 +1,397 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/docshelf/docshelf"
+	"github.com/pkg/errors"
+)
+
+// SigningMethod picks which algorithm a TokenIssuer signs access tokens with.
+type SigningMethod string
+
+const (
+	// HS256 signs tokens with a single shared secret. Simplest to configure,
+	// but every verifier needs the secret.
+	HS256 SigningMethod = "HS256"
+
+	// RS256 signs tokens with an RSA keypair. Verifiers only need the public
+	// key, which is what makes the JWKS endpoint possible.
+	RS256 SigningMethod = "RS256"
+)
+
+// Claims is the JWT claim set skribe issues for an authenticated session.
+type Claims struct {
+	jwt.StandardClaims
+	Skribe SkribeClaims `json:"skribe"`
+}
+
+// SkribeClaims carries the authorization data a request needs without a
+// round trip to the GroupStore or PolicyStore.
+type SkribeClaims struct {
+	Groups   []string `json:"groups"`
+	Policies []string `json:"policies"`
+}
+
+// A Token is handed back to a client after a successful login, oauth
+// callback, or refresh.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// TokenIssuer mints and validates the JWTs skribe uses for session state.
+// Implementations own their signing-key lifecycle, including rotation, and
+// their refresh-token bookkeeping.
+type TokenIssuer interface {
+	// Issue mints a new access/refresh token pair for user.
+	Issue(ctx context.Context, user docshelf.User) (Token, error)
+
+	// Refresh exchanges a still-valid refresh token for a new pair, rotating
+	// the refresh token so the old one can no longer be used.
+	Refresh(ctx context.Context, refreshToken string) (Token, error)
+
+	// Validate parses and verifies an access token, returning its claims.
+	Validate(ctx context.Context, accessToken string) (Claims, error)
+
+	// JWKS returns the issuer's current public keys in JWK Set form. Issuers
+	// that only support HS256 should return an empty set.
+	JWKS(ctx context.Context) (JWKS, error)
+}
+
+// JWK is a single entry in a JSON Web Key Set.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is the response shape served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// refreshRecord tracks the user a refresh token belongs to and when it
+// expires, so Refresh can validate and rotate it.
+type refreshRecord struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// rsaKey is one generation of signing key. kid lets Validate pick the right
+// public key for a token minted before a rotation.
+type rsaKey struct {
+	kid       string
+	key       *rsa.PrivateKey
+	createdAt time.Time
+}
+
+// keyRetention bounds how long a rotated-out signing key is still accepted
+// by Validate and served from JWKS before RotateKey prunes it, so i.keys
+// doesn't grow without bound over the life of the process. It's kept well
+// above any reasonable access-token ttl so rotating doesn't reject tokens
+// that were signed moments before.
+const keyRetention = 30 * 24 * time.Hour
+
+// pruneExpiredKeys drops rotated-out keys older than keyRetention. The
+// current (last) key is always kept regardless of age, since it's what new
+// tokens get signed with.
+func pruneExpiredKeys(keys []rsaKey) []rsaKey {
+	if len(keys) == 0 {
+		return keys
+	}
+
+	cutoff := time.Now().Add(-keyRetention)
+	kept := keys[:0:0]
+	for idx, k := range keys {
+		if idx == len(keys)-1 || k.createdAt.After(cutoff) {
+			kept = append(kept, k)
+		}
+	}
+
+	return kept
+}
+
+// JWTIssuer is the default TokenIssuer, backed by an in-memory refresh-token
+// table. It supports both HS256 and RS256; RS256 additionally publishes a
+// JWKS so downstream services can verify tokens without calling skribe.
+type JWTIssuer struct {
+	method   SigningMethod
+	issuer   string
+	ttl      time.Duration
+	groups   docshelf.GroupStore
+	policies docshelf.PolicyStore
+
+	secret []byte
+
+	mu   sync.RWMutex
+	keys []rsaKey // keys[len(keys)-1] is current; older entries stay valid for Validate.
+
+	refreshMu sync.Mutex
+	refresh   map[string]refreshRecord
+}
+
+// NewJWTIssuer builds a JWTIssuer. For HS256, secret must be non-empty; for
+// RS256, secret is ignored and an initial keypair is generated.
+func NewJWTIssuer(method SigningMethod, issuer string, ttl time.Duration, secret []byte, groups docshelf.GroupStore, policies docshelf.PolicyStore) (*JWTIssuer, error) {
+	iss := &JWTIssuer{
+		method:   method,
+		issuer:   issuer,
+		ttl:      ttl,
+		groups:   groups,
+		policies: policies,
+		secret:   secret,
+		refresh:  make(map[string]refreshRecord),
+	}
+
+	if method == RS256 {
+		if err := iss.RotateKey(); err != nil {
+			return nil, errors.Wrap(err, "failed to generate initial signing key")
+		}
+	} else if len(secret) == 0 {
+		return nil, errors.New("HS256 issuer requires a non-empty secret")
+	}
+
+	return iss, nil
+}
+
+// RotateKey generates a new RSA keypair and makes it the current signing
+// key. Older keys are kept around so tokens signed before the rotation can
+// still be validated, until they age out past keyRetention. To retire a key
+// immediately instead - e.g. because it's suspected compromised - use
+// RevokeKey.
+func (i *JWTIssuer) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate rsa key")
+	}
+
+	kid := fmt.Sprintf("%x", sha256.Sum256(key.PublicKey.N.Bytes()))[:16]
+
+	i.mu.Lock()
+	i.keys = pruneExpiredKeys(append(i.keys, rsaKey{kid: kid, key: key, createdAt: time.Now()}))
+	i.mu.Unlock()
+
+	return nil
+}
+
+// RevokeKey immediately removes a signing key by kid, rather than waiting
+// for it to age out of keyRetention via RotateKey. Tokens signed with a
+// revoked key fail Validate, and the key is no longer served from JWKS,
+// from this call onward. Revoking the current key makes the newest remaining
+// key the signing key; if no keys remain, the issuer can't sign new tokens
+// until RotateKey is called again.
+func (i *JWTIssuer) RevokeKey(kid string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for idx, k := range i.keys {
+		if k.kid == kid {
+			i.keys = append(i.keys[:idx], i.keys[idx+1:]...)
+			return nil
+		}
+	}
+
+	return errors.Errorf("unknown signing key: %s", kid)
+}
+
+func (i *JWTIssuer) currentKey() (rsaKey, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if len(i.keys) == 0 {
+		return rsaKey{}, errors.New("no signing keys available")
+	}
+
+	return i.keys[len(i.keys)-1], nil
+}
+
+func (i *JWTIssuer) keyByKid(kid string) (*rsa.PublicKey, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, k := range i.keys {
+		if k.kid == kid {
+			return &k.key.PublicKey, true
+		}
+	}
+
+	return nil, false
+}
+
+// Issue implements TokenIssuer.
+func (i *JWTIssuer) Issue(ctx context.Context, user docshelf.User) (Token, error) {
+	now := time.Now()
+
+	var groups []string
+	if i.groups != nil {
+		g, err := i.groups.GetGroups(ctx, user.ID)
+		if err != nil {
+			return Token{}, errors.Wrap(err, "failed to load groups for claims")
+		}
+		groups = g
+	}
+
+	var policies []string
+	if i.policies != nil {
+		p, err := i.policies.GetPolicies(ctx, user.ID)
+		if err != nil {
+			return Token{}, errors.Wrap(err, "failed to load policies for claims")
+		}
+		policies = p
+	}
+
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user.ID,
+			Issuer:    i.issuer,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(i.ttl).Unix(),
+		},
+		Skribe: SkribeClaims{
+			Groups:   groups,
+			Policies: policies,
+		},
+	}
+
+	access, err := i.sign(claims)
+	if err != nil {
+		return Token{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return Token{}, errors.Wrap(err, "failed to generate refresh token")
+	}
+
+	i.refreshMu.Lock()
+	i.refresh[refreshToken] = refreshRecord{userID: user.ID, expiresAt: now.Add(30 * 24 * time.Hour)}
+	i.refreshMu.Unlock()
+
+	return Token{
+		AccessToken:  access,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(i.ttl.Seconds()),
+	}, nil
+}
+
+// Refresh implements TokenIssuer. The supplied refresh token is revoked
+// whether or not the exchange succeeds, so a leaked or replayed token can
+// only be used once.
+func (i *JWTIssuer) Refresh(ctx context.Context, refreshToken string) (Token, error) {
+	i.refreshMu.Lock()
+	record, ok := i.refresh[refreshToken]
+	delete(i.refresh, refreshToken)
+	i.refreshMu.Unlock()
+
+	if !ok {
+		return Token{}, errors.New("unknown or already-used refresh token")
+	}
+
+	if time.Now().After(record.expiresAt) {
+		return Token{}, errors.New("refresh token expired")
+	}
+
+	return i.Issue(ctx, docshelf.User{ID: record.userID})
+}
+
+// Validate implements TokenIssuer.
+func (i *JWTIssuer) Validate(ctx context.Context, accessToken string) (Claims, error) {
+	var claims Claims
+
+	token, err := jwt.ParseWithClaims(accessToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		switch i.method {
+		case RS256:
+			if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+
+			kid, _ := t.Header["kid"].(string)
+			key, ok := i.keyByKid(kid)
+			if !ok {
+				return nil, errors.Errorf("unknown signing key: %s", kid)
+			}
+
+			return key, nil
+		default:
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+
+			return i.secret, nil
+		}
+	})
+
+	if err != nil {
+		return Claims{}, errors.Wrap(err, "failed to parse token")
+	}
+
+	if !token.Valid {
+		return Claims{}, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// JWKS implements TokenIssuer.
+func (i *JWTIssuer) JWKS(ctx context.Context) (JWKS, error) {
+	if i.method != RS256 {
+		return JWKS{Keys: []JWK{}}, nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(i.keys))}
+	for _, k := range i.keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: string(RS256),
+			N:   base64.RawURLEncoding.EncodeToString(k.key.PublicKey.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.PublicKey.E)).Bytes()),
+		})
+	}
+
+	return set, nil
+}
+
+func (i *JWTIssuer) sign(claims Claims) (string, error) {
+	switch i.method {
+	case RS256:
+		key, err := i.currentKey()
+		if err != nil {
+			return "", err
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = key.kid
+		return token.SignedString(key.key)
+	default:
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(i.secret)
+	}
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
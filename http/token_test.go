@@ -0,0 +1,170 @@
+package http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docshelf/docshelf"
+)
+
+func TestJWTIssuerIssueAndValidateHS256(t *testing.T) {
+	issuer, err := NewJWTIssuer(HS256, "skribe-test", time.Hour, []byte("super-secret"), nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	user := docshelf.User{ID: "user-1"}
+	token, err := issuer.Issue(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+
+	if token.AccessToken == "" || token.RefreshToken == "" {
+		t.Fatal("expected both an access and a refresh token")
+	}
+
+	claims, err := issuer.Validate(context.Background(), token.AccessToken)
+	if err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	if claims.Subject != user.ID {
+		t.Errorf("claims.Subject = %q, want %q", claims.Subject, user.ID)
+	}
+}
+
+func TestJWTIssuerValidateRejectsTamperedToken(t *testing.T) {
+	issuer, err := NewJWTIssuer(HS256, "skribe-test", time.Hour, []byte("super-secret"), nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	token, err := issuer.Issue(context.Background(), docshelf.User{ID: "user-1"})
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+
+	other, err := NewJWTIssuer(HS256, "skribe-test", time.Hour, []byte("a-different-secret"), nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	if _, err := other.Validate(context.Background(), token.AccessToken); err == nil {
+		t.Error("expected a token signed with a different secret to fail validation")
+	}
+}
+
+func TestJWTIssuerRefreshRotatesAndRevokes(t *testing.T) {
+	issuer, err := NewJWTIssuer(HS256, "skribe-test", time.Hour, []byte("super-secret"), nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	first, err := issuer.Issue(context.Background(), docshelf.User{ID: "user-1"})
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+
+	second, err := issuer.Refresh(context.Background(), first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("expected Refresh to rotate to a new refresh token")
+	}
+
+	if _, err := issuer.Refresh(context.Background(), first.RefreshToken); err == nil {
+		t.Error("expected a spent refresh token to be rejected on reuse")
+	}
+}
+
+func TestJWTIssuerRS256RoundTripAndRotation(t *testing.T) {
+	issuer, err := NewJWTIssuer(RS256, "skribe-test", time.Hour, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	token, err := issuer.Issue(context.Background(), docshelf.User{ID: "user-1"})
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+
+	if _, err := issuer.Validate(context.Background(), token.AccessToken); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+
+	if err := issuer.RotateKey(); err != nil {
+		t.Fatalf("RotateKey returned error: %v", err)
+	}
+
+	if _, err := issuer.Validate(context.Background(), token.AccessToken); err != nil {
+		t.Errorf("a token signed before rotation should still validate: %v", err)
+	}
+
+	jwks, err := issuer.JWKS(context.Background())
+	if err != nil {
+		t.Fatalf("JWKS returned error: %v", err)
+	}
+
+	if len(jwks.Keys) != 2 {
+		t.Errorf("expected both the original and rotated key in the JWKS, got %d", len(jwks.Keys))
+	}
+}
+
+func TestJWTIssuerRevokeKeyRejectsFurtherValidation(t *testing.T) {
+	issuer, err := NewJWTIssuer(RS256, "skribe-test", time.Hour, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer returned error: %v", err)
+	}
+
+	token, err := issuer.Issue(context.Background(), docshelf.User{ID: "user-1"})
+	if err != nil {
+		t.Fatalf("Issue returned error: %v", err)
+	}
+
+	if err := issuer.RotateKey(); err != nil {
+		t.Fatalf("RotateKey returned error: %v", err)
+	}
+
+	issuer.mu.RLock()
+	revokedKid := issuer.keys[0].kid
+	issuer.mu.RUnlock()
+
+	if err := issuer.RevokeKey(revokedKid); err != nil {
+		t.Fatalf("RevokeKey returned error: %v", err)
+	}
+
+	if _, err := issuer.Validate(context.Background(), token.AccessToken); err == nil {
+		t.Error("expected a token signed with a revoked key to fail validation")
+	}
+
+	if err := issuer.RevokeKey(revokedKid); err == nil {
+		t.Error("expected revoking an already-revoked kid to return an error")
+	}
+}
+
+func TestPruneExpiredKeysKeepsCurrentKeyRegardlessOfAge(t *testing.T) {
+	old := rsaKey{kid: "old", createdAt: time.Now().Add(-2 * keyRetention)}
+	recent := rsaKey{kid: "recent", createdAt: time.Now()}
+	current := rsaKey{kid: "current", createdAt: time.Now().Add(-2 * keyRetention)}
+
+	kept := pruneExpiredKeys([]rsaKey{old, recent, current})
+
+	var kids []string
+	for _, k := range kept {
+		kids = append(kids, k.kid)
+	}
+
+	want := map[string]bool{"recent": true, "current": true}
+	if len(kids) != len(want) {
+		t.Fatalf("pruneExpiredKeys(...) kept %v, want %d entries", kids, len(want))
+	}
+
+	for _, kid := range kids {
+		if !want[kid] {
+			t.Errorf("unexpected key %q survived pruning", kid)
+		}
+	}
+}
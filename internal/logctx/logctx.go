@@ -0,0 +1,68 @@
+// Package logctx threads a *slog.Logger through a context.Context, so a
+// logger stamped with request-scoped fields at the edge of the HTTP layer
+// stays attached all the way down to the store calls it triggers.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+type contextKey string
+
+const loggerKey = contextKey("logctx-logger")
+
+// WithLogger returns a copy of ctx carrying logger. Subsequent calls to
+// FromContext on that ctx (or any derived from it) return logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger stashed in ctx by WithLogger, or
+// slog.Default() if none was set. Callers never need to nil-check the
+// result.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// Options configures a logger built by New.
+type Options struct {
+	// Level is one of "debug", "info", "warn", "error". Defaults to "info".
+	Level string
+
+	// Format is "json" or "text". Defaults to "json".
+	Format string
+}
+
+// New builds a *slog.Logger writing to stderr according to opts.
+func New(opts Options) *slog.Logger {
+	level := parseLevel(opts.Level)
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if opts.Format == "text" {
+		handler = slog.NewTextHandler(os.Stderr, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stderr, handlerOpts)
+	}
+
+	return slog.New(handler)
+}
+
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}